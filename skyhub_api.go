@@ -0,0 +1,320 @@
+// REST API for the Skyhub image asset collection.
+// Exposes /skyhub/images for listing/creating and /skyhub/images/{name}
+// for reading, updating and deleting a single MongoDocument.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Request body accepted by POST /skyhub/images.
+type CreateImageRequest struct {
+	Url string
+	Description string
+	Tags []string
+}
+
+// Request body accepted by PUT /skyhub/images/{name}.
+// Only Description and Tags can be changed; Name and Url are immutable.
+type UpdateImageRequest struct {
+	Description string
+	Tags []string
+}
+
+// Builds the router for the Skyhub REST API and wires it to the
+// existing Skyhub handler and the static file server.
+func newRouter() *mux.Router {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/skyhub/images", ListImages).Methods("GET")
+	r.HandleFunc("/skyhub/images", CreateImage).Methods("POST")
+	r.HandleFunc("/skyhub/images/{name}", GetImage).Methods("GET")
+	r.HandleFunc("/skyhub/images/{name}", UpdateImage).Methods("PUT")
+	r.HandleFunc("/skyhub/images/{name}", DeleteImage).Methods("DELETE")
+	r.HandleFunc("/skyhub/similar/{name}", FindSimilar).Methods("GET")
+	r.HandleFunc("/skyhub/placeholder/{name}.png", PlaceholderImage).Methods("GET")
+
+	r.HandleFunc("/skyhub", Skyhub).Methods("GET")
+	r.PathPrefix("/skyhub/").Handler(http.StripPrefix("/skyhub/", http.FileServer(http.Dir("skyhub"))))
+
+	return r
+}
+
+// Lists documents, optionally paginated with ?page=&limit= and filtered
+// by ?name= (substring match) and ?size= (variant name, e.g. "small").
+func ListImages(w http.ResponseWriter, req *http.Request) {
+	db, err := connectToDatabase(DatabaseAddr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var conds []bson.M
+	if name := req.URL.Query().Get("name"); name != "" {
+		conds = append(conds, bson.M{"name": bson.RegEx{Pattern: name}})
+	}
+	if size := req.URL.Query().Get("size"); size != "" {
+		conds = append(conds, bson.M{"name": bson.RegEx{Pattern: "_" + size + "\\."}})
+	}
+
+	query := bson.M{}
+	switch len(conds) {
+	case 1:
+		query = conds[0]
+	case 2:
+		query["$and"] = conds
+	}
+
+	q := db.Find(query)
+
+	limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+	page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+	if limit > 0 {
+		q = q.Limit(limit)
+		if page > 0 {
+			q = q.Skip(page * limit)
+		}
+	}
+
+	var results []MongoDocument
+	if err := q.All(&results); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, results)
+}
+
+// Registers a new source URL: downloads it, resizes it into the configured
+// Variant set and stores the results, then returns the created documents.
+func CreateImage(w http.ResponseWriter, req *http.Request) {
+	var in CreateImageRequest
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.Url == "" {
+		http.Error(w, "Url is required.", http.StatusBadRequest)
+		return
+	}
+
+	db, err := connectToDatabase(DatabaseAddr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	src := Url{Url: in.Url, Description: in.Description, Tags: in.Tags}
+	report, err := Ingest(req.Context(), []Url{src}, IngestOptions{Parallelism: 1}, db, Store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if report.Downloaded == 0 && report.Skipped == 0 {
+		http.Error(w, fmt.Sprintf("Can't ingest %v: %v", in.Url, report.Errors), http.StatusBadGateway)
+		return
+	}
+
+	err, baseName := nameFromUrl(in.Url)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var created []MongoDocument
+	if err := db.Find(bson.M{"name": bson.RegEx{Pattern: "^" + baseName + "_"}}).All(&created); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if report.Downloaded == 0 {
+		// Every source was already in the store; nothing was created.
+		writeJson(w, created)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	writeJson(w, created)
+}
+
+// Retrieves a single MongoDocument by its exact Name.
+func GetImage(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+
+	db, err := connectToDatabase(DatabaseAddr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var doc MongoDocument
+	if err := db.Find(bson.M{"name": name}).One(&doc); err != nil {
+		http.Error(w, "Image not found.", http.StatusNotFound)
+		return
+	}
+
+	writeJson(w, doc)
+}
+
+// Updates the Description and Tags of an existing MongoDocument.
+func UpdateImage(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+
+	var in UpdateImageRequest
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db, err := connectToDatabase(DatabaseAddr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	change := bson.M{"$set": bson.M{"description": in.Description, "tags": in.Tags}}
+	if err := db.Update(bson.M{"name": name}, change); err != nil {
+		http.Error(w, "Image not found.", http.StatusNotFound)
+		return
+	}
+
+	var doc MongoDocument
+	if err := db.Find(bson.M{"name": name}).One(&doc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, doc)
+}
+
+// Removes the MongoDocument and its underlying storage object.
+func DeleteImage(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+
+	db, err := connectToDatabase(DatabaseAddr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := db.Remove(bson.M{"name": name}); err != nil {
+		http.Error(w, "Image not found.", http.StatusNotFound)
+		return
+	}
+
+	if err := Store.Delete(req.Context(), name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Returns documents whose pHash is within ?threshold= (default 10) Hamming
+// distance of the target document's pHash, for detecting near-duplicate
+// images that differ by bytes (e.g. re-encodes) but look the same.
+func FindSimilar(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+
+	threshold := 10
+	if raw := req.URL.Query().Get("threshold"); raw != "" {
+		t, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid threshold.", http.StatusBadRequest)
+			return
+		}
+		threshold = t
+	}
+
+	db, err := connectToDatabase(DatabaseAddr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var target MongoDocument
+	if err := db.Find(bson.M{"name": name}).One(&target); err != nil {
+		http.Error(w, "Image not found.", http.StatusNotFound)
+		return
+	}
+
+	// Every variant of the same source shares its Sha256 and an identical
+	// Phash, so excluding only the exact name would report siblings of
+	// the target as "near-duplicates". Excluding the whole source keeps
+	// the results to genuine cross-source re-encodes.
+	var candidates []MongoDocument
+	if err := db.Find(bson.M{"sha256": bson.M{"$ne": target.Sha256}}).All(&candidates); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	similar := make([]MongoDocument, 0, len(candidates))
+	for _, doc := range candidates {
+		if hammingDistance(target.Phash, doc.Phash) <= threshold {
+			similar = append(similar, doc)
+		}
+	}
+
+	writeJson(w, similar)
+}
+
+// Decodes the BlurHash of the source image named {name} (without its
+// variant suffix) and returns it as a small PNG, for clients that can't
+// render a BlurHash themselves.
+func PlaceholderImage(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+
+	width := 32
+	if raw := req.URL.Query().Get("w"); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil && v > 0 {
+			width = v
+		}
+	}
+	height := 32
+	if raw := req.URL.Query().Get("h"); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil && v > 0 {
+			height = v
+		}
+	}
+
+	db, err := connectToDatabase(DatabaseAddr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var doc MongoDocument
+	if err := db.Find(bson.M{"name": bson.RegEx{Pattern: "^" + name + "_"}}).One(&doc); err != nil {
+		http.Error(w, "Image not found.", http.StatusNotFound)
+		return
+	}
+
+	placeholder, err := decodeBlurhash(doc.Blurhash, width, height)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, placeholder); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Pretty-prints v as JSON to w.
+func writeJson(w http.ResponseWriter, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,89 @@
+// Tests the /skyhub/images CRUD routes.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Starts the CRUD router on a local test server and exercises
+// POST, GET (list and single), PUT and DELETE against it.
+func TestImagesCRUD(t *testing.T) {
+	server := httptest.NewServer(newRouter())
+	defer server.Close()
+
+	// POST: register a new source URL.
+	createReq := CreateImageRequest{
+		Url: "http://54.152.221.29/images/b737_3.jpg",
+		Description: "A 737",
+		Tags: []string{"plane", "boeing"},
+	}
+	body, err := json.Marshal(createReq)
+	if err != nil {
+		t.Fatalf("Can't marshal create request: %v!", err)
+	}
+
+	resp, err := http.Post(server.URL+"/skyhub/images", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Can't POST /skyhub/images: %v!", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status %v, got %v!", http.StatusCreated, resp.StatusCode)
+	}
+
+	var created []MongoDocument
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("Can't decode create response: %v!", err)
+	}
+	if len(created) != 3 {
+		t.Fatalf("Expected 3 resized variants, got %v!", len(created))
+	}
+	name := created[0].Name
+
+	// GET: retrieve the single document.
+	getResp, err := http.Get(server.URL + "/skyhub/images/" + name)
+	if err != nil {
+		t.Fatalf("Can't GET /skyhub/images/%v: %v!", name, err)
+	}
+	defer getResp.Body.Close()
+	if getResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v!", http.StatusOK, getResp.StatusCode)
+	}
+
+	// PUT: change the description.
+	updateReq := UpdateImageRequest{Description: "Updated description", Tags: []string{"plane"}}
+	updateBody, err := json.Marshal(updateReq)
+	if err != nil {
+		t.Fatalf("Can't marshal update request: %v!", err)
+	}
+	req, err := http.NewRequest(http.MethodPut, server.URL+"/skyhub/images/"+name, bytes.NewReader(updateBody))
+	if err != nil {
+		t.Fatalf("Can't build PUT request: %v!", err)
+	}
+	putResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Can't PUT /skyhub/images/%v: %v!", name, err)
+	}
+	defer putResp.Body.Close()
+	if putResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v!", http.StatusOK, putResp.StatusCode)
+	}
+
+	// DELETE: remove the document and its file.
+	delReq, err := http.NewRequest(http.MethodDelete, server.URL+"/skyhub/images/"+name, nil)
+	if err != nil {
+		t.Fatalf("Can't build DELETE request: %v!", err)
+	}
+	delResp, err := http.DefaultClient.Do(delReq)
+	if err != nil {
+		t.Fatalf("Can't DELETE /skyhub/images/%v: %v!", name, err)
+	}
+	defer delResp.Body.Close()
+	if delResp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status %v, got %v!", http.StatusNoContent, delResp.StatusCode)
+	}
+}
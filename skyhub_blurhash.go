@@ -0,0 +1,27 @@
+// BlurHash placeholders: a compact base83 string clients can render as
+// a blurred preview while the real variant is still loading.
+package main
+
+import (
+	"image"
+
+	"github.com/buckket/go-blurhash"
+)
+
+// Number of DCT components used to encode the BlurHash, and the punch
+// (contrast factor) applied when decoding it.
+const (
+	blurhashComponentsX = 4
+	blurhashComponentsY = 3
+	blurhashPunch = 1
+)
+
+// Computes the BlurHash string for img.
+func encodeBlurhash(img image.Image) (string, error) {
+	return blurhash.Encode(blurhashComponentsX, blurhashComponentsY, img)
+}
+
+// Renders hash back into a small placeholder image of the given size.
+func decodeBlurhash(hash string, width, height int) (image.Image, error) {
+	return blurhash.Decode(hash, width, height, blurhashPunch)
+}
@@ -0,0 +1,33 @@
+// Tests the BlurHash encode/decode round-trip.
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlurhashRoundTrip(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 8), G: uint8(y * 8), B: 128, A: 255})
+		}
+	}
+
+	hash, err := encodeBlurhash(img)
+	if err != nil {
+		t.Fatalf("encodeBlurhash failed: %v!", err)
+	}
+	if hash == "" {
+		t.Fatalf("Expected a non-empty BlurHash!")
+	}
+
+	placeholder, err := decodeBlurhash(hash, 16, 16)
+	if err != nil {
+		t.Fatalf("decodeBlurhash failed: %v!", err)
+	}
+	if placeholder.Bounds().Dx() != 16 || placeholder.Bounds().Dy() != 16 {
+		t.Fatalf("Expected a 16x16 placeholder, got %vx%v!", placeholder.Bounds().Dx(), placeholder.Bounds().Dy())
+	}
+}
@@ -0,0 +1,52 @@
+// Generic image decoding/encoding: jpeg, png and gif are decoded and
+// encoded through the standard library; webp is decoded (but not
+// encoded, since the standard library has no webp encoder) via
+// golang.org/x/image/webp.
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Decodes an image of any registered format, returning the decoded
+// image and the format name ("jpeg", "png", "gif" or "webp").
+func decodeImage(r io.Reader) (image.Image, string, error) {
+	return image.Decode(r)
+}
+
+// Encodes img to w using the format and quality described by v.
+func encodeVariant(w io.Writer, img image.Image, v Variant) error {
+	switch v.Format {
+	case "", "jpeg":
+		quality := v.Quality
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return fmt.Errorf("unsupported encode format %q", v.Format)
+	}
+}
+
+// File extension used for a variant's Format.
+func extensionFor(format string) string {
+	switch format {
+	case "png":
+		return "png"
+	case "gif":
+		return "gif"
+	default:
+		return "jpg"
+	}
+}
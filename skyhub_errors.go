@@ -0,0 +1,43 @@
+// Structured per-URL errors surfaced in an IngestReport, so a caller
+// can tell a slow origin apart from a hostile payload.
+package main
+
+// Broad category of an IngestError, used to let callers react
+// differently (e.g. retry a timeout but not a bad-format).
+type IngestErrorKind string
+
+const (
+	ErrTimeout IngestErrorKind = "timeout"
+	ErrConnFailed IngestErrorKind = "conn-failed"
+	ErrTooBig IngestErrorKind = "too-big"
+	ErrBadFormat IngestErrorKind = "bad-format"
+	ErrDecodeFailed IngestErrorKind = "decode-failed"
+)
+
+// Retryable reports whether a failure of this Kind is worth retrying:
+// transient network trouble (a slow origin or a dropped connection) is,
+// a permanent classification of the payload itself is not.
+func (k IngestErrorKind) Retryable() bool {
+	switch k {
+	case ErrTimeout, ErrConnFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wraps a per-URL ingestion failure with its Kind, so IngestReport.Errors
+// carries enough structure for a caller to act on without string-matching.
+type IngestError struct {
+	Url string
+	Kind IngestErrorKind
+	Err error
+}
+
+func (e *IngestError) Error() string {
+	return e.Url + " (" + string(e.Kind) + "): " + e.Err.Error()
+}
+
+func (e *IngestError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,123 @@
+// Table-driven tests for the size-capped, format-sniffing ingestion
+// path, each serving a pathological payload over httptest.Server.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Builds a well-formed PNG IHDR chunk declaring width x height, with no
+// further chunks. image/png's DecodeConfig only needs to read IHDR, so
+// this lets a test claim a huge declared size with a tiny payload,
+// exactly the decompression-bomb shape fetchCapped defends against.
+func pngWithDeclaredSize(width, height uint32) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'})
+
+	var ihdr bytes.Buffer
+	binary.Write(&ihdr, binary.BigEndian, width)
+	binary.Write(&ihdr, binary.BigEndian, height)
+	ihdr.Write([]byte{8, 6, 0, 0, 0}) // bit depth 8, color type 6 (RGBA), rest default
+
+	var chunk bytes.Buffer
+	binary.Write(&chunk, binary.BigEndian, uint32(ihdr.Len()))
+	chunk.WriteString("IHDR")
+	chunk.Write(ihdr.Bytes())
+	crc := crc32.ChecksumIEEE(append([]byte("IHDR"), ihdr.Bytes()...))
+	binary.Write(&chunk, binary.BigEndian, crc)
+
+	buf.Write(chunk.Bytes())
+	return buf.Bytes()
+}
+
+func validPng(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 16), G: uint8(y * 16), B: 0, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Can't build test fixture PNG: %v!", err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetchCappedRejectsPathologicalPayloads(t *testing.T) {
+	cases := []struct {
+		name string
+		body []byte
+		opts IngestOptions
+		wantKind IngestErrorKind
+	}{
+		{
+			name: "oversized body",
+			body: append(pngWithDeclaredSize(10, 10), bytes.Repeat([]byte{0}, 1024)...),
+			opts: IngestOptions{MaxBytes: 100},
+			wantKind: ErrTooBig,
+		},
+		{
+			name: "garbage, not an image",
+			body: []byte("this is not an image"),
+			opts: IngestOptions{},
+			wantKind: ErrBadFormat,
+		},
+		{
+			name: "declared pixel count exceeds budget",
+			body: pngWithDeclaredSize(100000, 100000),
+			opts: IngestOptions{MaxPixels: 1000000},
+			wantKind: ErrTooBig,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(c.body)
+			}))
+			defer server.Close()
+
+			opts := c.opts.withDefaults()
+			_, err := fetchCapped(context.Background(), server.URL, opts)
+			if err == nil {
+				t.Fatalf("Expected an error, got none!")
+			}
+
+			var ingestErr *IngestError
+			if !errors.As(err, &ingestErr) {
+				t.Fatalf("Expected an *IngestError, got %T: %v!", err, err)
+			}
+			if ingestErr.Kind != c.wantKind {
+				t.Fatalf("Expected kind %v, got %v!", c.wantKind, ingestErr.Kind)
+			}
+		})
+	}
+}
+
+// A well-formed, small image within budget should be accepted.
+func TestFetchCappedAcceptsValidImage(t *testing.T) {
+	body := validPng(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	data, err := fetchCapped(context.Background(), server.URL, IngestOptions{}.withDefaults())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v!", err)
+	}
+	if len(data) != len(body) {
+		t.Fatalf("Expected %v bytes, got %v!", len(body), len(data))
+	}
+}
@@ -0,0 +1,114 @@
+// Content hashing used for deduplication: a strong SHA-256 of the raw
+// source bytes for exact-copy detection, and a 64-bit perceptual hash
+// (pHash) for near-duplicate detection across re-encodes.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/nfnt/resize"
+)
+
+// Hex-encoded SHA-256 of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Computes a 64-bit DCT-based perceptual hash of img: resize to 32x32,
+// convert to grayscale, run a 2D DCT, keep the top-left 8x8 block
+// (the lowest frequencies), and set bit i to 1 if that coefficient is
+// above the median of the block (the DC term at [0][0] is excluded
+// from the median, since it is dominated by the average brightness).
+func phash(img image.Image) uint64 {
+	gray := toGray32x32(img)
+	coeffs := dct8x8(gray)
+
+	values := make([]float64, 0, 63)
+	for u := 0; u < 8; u++ {
+		for v := 0; v < 8; v++ {
+			if u == 0 && v == 0 {
+				continue
+			}
+			values = append(values, coeffs[u][v])
+		}
+	}
+	med := median(values)
+
+	var hash uint64
+	bit := uint(0)
+	for u := 0; u < 8; u++ {
+		for v := 0; v < 8; v++ {
+			if coeffs[u][v] > med {
+				hash |= 1 << bit
+			}
+			bit++
+		}
+	}
+	return hash
+}
+
+// Hamming distance between two pHash values.
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Resizes img to 32x32 and returns its grayscale luma values.
+func toGray32x32(img image.Image) [32][32]float64 {
+	small := resize.Resize(32, 32, img, resize.Bilinear)
+	bounds := small.Bounds()
+
+	var out [32][32]float64
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			c := small.At(bounds.Min.X+x, bounds.Min.Y+y)
+			out[y][x] = float64(color.GrayModel.Convert(c).(color.Gray).Y)
+		}
+	}
+	return out
+}
+
+// Runs a 2D DCT-II over the 32x32 grayscale matrix f, returning only
+// the top-left 8x8 block of coefficients (the lowest frequencies).
+func dct8x8(f [32][32]float64) [8][8]float64 {
+	const n = 32
+
+	var out [8][8]float64
+	for u := 0; u < 8; u++ {
+		for v := 0; v < 8; v++ {
+			var sum float64
+			for x := 0; x < n; x++ {
+				for y := 0; y < n; y++ {
+					sum += f[x][y] *
+						math.Cos(float64(2*x+1)*float64(u)*math.Pi/(2*n)) *
+						math.Cos(float64(2*y+1)*float64(v)*math.Pi/(2*n))
+				}
+			}
+			cu, cv := 1.0, 1.0
+			if u == 0 {
+				cu = 1 / math.Sqrt2
+			}
+			if v == 0 {
+				cv = 1 / math.Sqrt2
+			}
+			out[u][v] = 0.25 * cu * cv * sum
+		}
+	}
+	return out
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
@@ -0,0 +1,44 @@
+// Tests the perceptual hash used for near-duplicate detection.
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// Builds a deterministic gradient image for hashing.
+func gradientImage(w, h int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8((x + y) % 256)})
+		}
+	}
+	return img
+}
+
+// The same image hashed twice must produce an identical pHash.
+func TestPhashIsDeterministic(t *testing.T) {
+	img := gradientImage(64, 64)
+	if phash(img) != phash(img) {
+		t.Fatalf("Expected pHash to be deterministic for the same image!")
+	}
+}
+
+// A visually different image should hash to a noticeably different value.
+func TestPhashDiffersForDifferentImages(t *testing.T) {
+	a := phash(gradientImage(64, 64))
+
+	solid := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			solid.SetGray(x, y, color.Gray{Y: 200})
+		}
+	}
+	b := phash(solid)
+
+	if hammingDistance(a, b) == 0 {
+		t.Fatalf("Expected different images to have a non-zero Hamming distance!")
+	}
+}
@@ -0,0 +1,343 @@
+// Concurrent ingestion pipeline: fetches source URLs, decodes them,
+// resizes each into the configured Variant set and stores the results,
+// all as a set of goroutine stages connected by channels.
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Tuning knobs for Ingest. Zero values fall back to sane defaults,
+// except MaxRetries, where zero means no retries.
+type IngestOptions struct {
+	// Number of concurrent fetcher workers.
+	Parallelism int
+	// Per-attempt HTTP timeout.
+	Timeout time.Duration
+	// Number of retries after the first failed attempt, with backoff.
+	// Zero disables retries; negative falls back to the default like
+	// the other fields.
+	MaxRetries int
+	// Largest response body accepted, in bytes.
+	MaxBytes int64
+	// Largest declared Width*Height accepted, to reject decompression bombs.
+	MaxPixels int64
+}
+
+func (opts IngestOptions) withDefaults() IngestOptions {
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = 4
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 2
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 5 * 1024 * 1024
+	}
+	if opts.MaxPixels <= 0 {
+		opts.MaxPixels = 25000000
+	}
+	return opts
+}
+
+// Summarizes the outcome of an Ingest call.
+type IngestReport struct {
+	Downloaded int
+	Skipped int
+	Failed int
+	Errors []error
+}
+
+type fetchedImg struct {
+	url Url
+	data []byte
+}
+
+type resizedImg struct {
+	info ImageInfo
+	variant Variant
+	img image.Image
+}
+
+// Downloads urls, resizes each into the configured Variant set, writes
+// the variants to store and upserts the results into db. db may be nil,
+// in which case dedupe lookups and upserts are both skipped. Runs as
+// four pipeline stages (fetch, decode, resize, sink) joined by buffered
+// channels, so one bad image does not stop the rest of the batch:
+// every stage error is collected into the returned IngestReport
+// instead of aborting the pipeline.
+func Ingest(ctx context.Context, urls []Url, opts IngestOptions, db *mgo.Collection, store Storage) (IngestReport, error) {
+	opts = opts.withDefaults()
+
+	variants, err := loadVariants()
+	if err != nil {
+		return IngestReport{}, err
+	}
+
+	var mu sync.Mutex
+	report := IngestReport{}
+	recordErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		report.Failed++
+		report.Errors = append(report.Errors, err)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	toFetch := make(chan Url)
+	fetchedCh := make(chan fetchedImg, opts.Parallelism)
+	decodedCh := make(chan ImageInfo, opts.Parallelism)
+	resizedCh := make(chan resizedImg, opts.Parallelism)
+
+	// Feeds the URLs to whichever fetcher worker is free.
+	g.Go(func() error {
+		defer close(toFetch)
+		for _, u := range urls {
+			select {
+			case toFetch <- u:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	// Fetcher stage: N workers download originals with retry/backoff.
+	var fetchWg sync.WaitGroup
+	for i := 0; i < opts.Parallelism; i++ {
+		fetchWg.Add(1)
+		g.Go(func() error {
+			defer fetchWg.Done()
+			for u := range toFetch {
+				data, err := fetchWithRetry(ctx, u.Url, opts)
+				if err != nil {
+					recordErr(err)
+					continue
+				}
+				select {
+				case fetchedCh <- fetchedImg{url: u, data: data}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	}
+	go func() {
+		fetchWg.Wait()
+		close(fetchedCh)
+	}()
+
+	// Decoder stage. Also computes the SHA-256 of the source bytes and
+	// skips images that already exist in db under that hash, so a URL
+	// that re-serves bytes we already have doesn't pay for a resize.
+	g.Go(func() error {
+		for f := range fetchedCh {
+			img, _, err := decodeImage(bytes.NewReader(f.data))
+			if err != nil {
+				recordErr(&IngestError{Url: f.url.Url, Kind: ErrDecodeFailed, Err: err})
+				continue
+			}
+			err, name := nameFromUrl(f.url.Url)
+			if err != nil {
+				recordErr(&IngestError{Url: f.url.Url, Kind: ErrDecodeFailed, Err: err})
+				continue
+			}
+
+			sha := sha256Hex(f.data)
+			if db != nil {
+				var existing MongoDocument
+				if findErr := db.Find(bson.M{"sha256": sha}).One(&existing); findErr == nil {
+					mu.Lock()
+					report.Skipped++
+					mu.Unlock()
+					continue
+				}
+			}
+
+			hash, err := encodeBlurhash(img)
+			if err != nil {
+				recordErr(fmt.Errorf("blurhash %v: %w", f.url.Url, err))
+				continue
+			}
+
+			bounds := img.Bounds()
+			info := ImageInfo{
+				img: img,
+				name: name,
+				description: f.url.Description,
+				tags: f.url.Tags,
+				sha256: sha,
+				phash: phash(img),
+				blurhash: hash,
+				width: bounds.Dx(),
+				height: bounds.Dy(),
+			}
+			select {
+			case decodedCh <- info:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		close(decodedCh)
+		return nil
+	})
+
+	// Resizer stage: fans each source image out into the configured variants.
+	g.Go(func() error {
+		for info := range decodedCh {
+			mu.Lock()
+			report.Downloaded++
+			mu.Unlock()
+			for _, v := range variants {
+				select {
+				case resizedCh <- resizedImg{info: info, variant: v, img: resizeVariant(info.img, v)}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		close(resizedCh)
+		return nil
+	})
+
+	// Sink stage: writes files to storage and upserts into Mongo.
+	g.Go(func() error {
+		for r := range resizedCh {
+			name := r.info.name + "_" + r.variant.Name + "." + extensionFor(r.variant.Format)
+
+			var buf bytes.Buffer
+			if err := encodeVariant(&buf, r.img, r.variant); err != nil {
+				recordErr(fmt.Errorf("encode %v: %w", name, err))
+				continue
+			}
+			url, err := store.Put(ctx, name, &buf)
+			if err != nil {
+				recordErr(fmt.Errorf("save %v: %w", name, err))
+				continue
+			}
+			doc := MongoDocument{
+				Name: name,
+				Url: url,
+				Description: r.info.description,
+				Tags: r.info.tags,
+				Sha256: r.info.sha256,
+				Phash: r.info.phash,
+				Blurhash: r.info.blurhash,
+				Width: r.info.width,
+				Height: r.info.height,
+			}
+			if db != nil {
+				nameId := struct {
+					Name string
+				}{doc.Name}
+				if _, err := db.Upsert(nameId, doc); err != nil {
+					recordErr(fmt.Errorf("store %v: %w", name, err))
+				}
+			}
+		}
+		return nil
+	})
+
+	err = g.Wait()
+	return report, err
+}
+
+// Fetches and sniffs url's body, retrying up to opts.MaxRetries times
+// with linear backoff between attempts. Each attempt is bound by
+// opts.Timeout. Failures are classified into an *IngestError so
+// callers can tell a slow origin apart from a hostile payload; only
+// transient failures (IngestErrorKind.Retryable) are retried, so a
+// hostile or malformed payload is rejected on the first attempt
+// instead of being re-fetched up to the cap on every retry.
+func fetchWithRetry(ctx context.Context, url string, opts IngestOptions) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * 250 * time.Millisecond):
+			case <-ctx.Done():
+				return nil, &IngestError{Url: url, Kind: ErrTimeout, Err: ctx.Err()}
+			}
+		}
+
+		data, err := fetchCapped(ctx, url, opts)
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+		if ie, ok := err.(*IngestError); ok && !ie.Kind.Retryable() {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// Downloads url's body capped at opts.MaxBytes, rejecting it as soon
+// as its declared dimensions exceed opts.MaxPixels (defending against
+// decompression bombs) without reading the rest of the body. Peeks at
+// the header via image.DecodeConfig, then reassembles the buffered
+// header with the unread remainder for the caller's full image.Decode.
+func fetchCapped(ctx context.Context, url string, opts IngestOptions) ([]byte, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &IngestError{Url: url, Kind: ErrDecodeFailed, Err: err}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil || reqCtx.Err() == context.DeadlineExceeded {
+			return nil, &IngestError{Url: url, Kind: ErrTimeout, Err: err}
+		}
+		return nil, &IngestError{Url: url, Kind: ErrConnFailed, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &IngestError{Url: url, Kind: ErrDecodeFailed, Err: fmt.Errorf("unexpected status %v", resp.StatusCode)}
+	}
+
+	limited := io.LimitReader(resp.Body, opts.MaxBytes+1)
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(limited, &header))
+	if err != nil {
+		if reqCtx.Err() != nil {
+			return nil, &IngestError{Url: url, Kind: ErrTimeout, Err: err}
+		}
+		return nil, &IngestError{Url: url, Kind: ErrBadFormat, Err: err}
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > opts.MaxPixels {
+		return nil, &IngestError{Url: url, Kind: ErrTooBig, Err: fmt.Errorf("%dx%d exceeds the %d pixel budget", cfg.Width, cfg.Height, opts.MaxPixels)}
+	}
+
+	data, err := ioutil.ReadAll(io.MultiReader(&header, limited))
+	if err != nil {
+		if reqCtx.Err() != nil {
+			return nil, &IngestError{Url: url, Kind: ErrTimeout, Err: err}
+		}
+		return nil, &IngestError{Url: url, Kind: ErrDecodeFailed, Err: err}
+	}
+	if int64(len(data)) > opts.MaxBytes {
+		return nil, &IngestError{Url: url, Kind: ErrTooBig, Err: fmt.Errorf("body exceeds the %d byte budget", opts.MaxBytes)}
+	}
+	return data, nil
+}
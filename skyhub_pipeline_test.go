@@ -0,0 +1,30 @@
+// Tests the Ingest pipeline in isolation, without a database.
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// Ingesting an empty URL list should succeed trivially.
+func TestIngestNoUrls(t *testing.T) {
+	report, err := Ingest(context.Background(), nil, IngestOptions{}, nil, NewMemory(""))
+	if err != nil {
+		t.Fatalf("Ingest with no URLs returned an error: %v!", err)
+	}
+	if report.Downloaded != 0 || report.Failed != 0 {
+		t.Fatalf("Expected an empty report, got %+v!", report)
+	}
+}
+
+// A bad URL should be reported as a failure rather than aborting the batch.
+func TestIngestBadUrlIsReported(t *testing.T) {
+	bad := Url{Url: "http://127.0.0.1:1/does-not-exist.jpg"}
+	report, err := Ingest(context.Background(), []Url{bad}, IngestOptions{Parallelism: 1, MaxRetries: 0}, nil, NewMemory(""))
+	if err != nil {
+		t.Fatalf("Ingest returned an error: %v!", err)
+	}
+	if report.Failed != 1 {
+		t.Fatalf("Expected 1 failed image, got %v!", report.Failed)
+	}
+}
@@ -1,41 +1,43 @@
 // Implements the Skyhub Challenge.
 // Downloads all the images from the consume endpoint
-// and returns a JSON object with the name and URL for all the images 
-// resized to three different dimensions (Small, Medium and Large).
+// and returns a JSON object with the name and URL for all the images
+// resized into a configurable set of Variants (see skyhub_variants.go).
 //
-// Uses the local filesystem to store the images and uses MongoDB to retrieve the images URLs.
+// Stores the images through a pluggable Storage backend (local filesystem by
+// default, see skyhub_storage.go) and uses MongoDB to retrieve the images URLs.
 //
-// nfnt/resize package is used to resize the images. 
+// nfnt/resize package is used to resize the images.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/nfnt/resize"
 	"gopkg.in/mgo.v2"
 	"image"
-	"image/jpeg"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"os"
-	"strconv"
 	"strings"
 )
 
-type ImageSize struct {
-	width, height uint
-}
-
 type ImageInfo struct {
 	img image.Image
 	name string
+	description string
+	tags []string
+	sha256 string
+	phash uint64
+	blurhash string
+	width, height int
 }
 
 type Url struct {
 	Url string
+	Description string `json:",omitempty"`
+	Tags []string `json:",omitempty"`
 }
 
 // The format of the JSON input from the endpoint we consume.
@@ -47,13 +49,17 @@ type SkyhubResponse struct {
 // image location on the local file system.
 type MongoDocument struct {
 	Name, Url string
+	Description string
+	Tags []string
+	Sha256 string
+	Phash uint64
+	// BlurHash of the original source image, plus its original
+	// dimensions, so clients can render a placeholder immediately.
+	Blurhash string
+	Width, Height int
 }
 
 var (
-	smallSize = ImageSize{320, 240}
-	mediumSize = ImageSize{384, 288}
-	largeSize = ImageSize{640, 480}
-	
 	// The folder we are saving the images.
 	filepathPrefix = "/home/edufgf/Desktop/B2W/skyhub/"
 	
@@ -68,6 +74,11 @@ var (
 	
 	// Source of the images we consume.
 	EndpointAddr = "http://54.152.221.29/images.json"
+
+	// Where resized image files are written to and served from.
+	// Defaults to the local filesystem; swap for an *S3 or *Memory to
+	// change backends without touching the database schema.
+	Store Storage = NewLocalFS(filepathPrefix, "http://"+ServerAddr+"/skyhub/")
 )
 
 // The server handle function. It displays the JSON with the resized images URLs.
@@ -135,122 +146,48 @@ func nameFromUrl(imgurl string) (error, string) {
 	return nil, imgurl[leftIndex:rightIndex]
 }
 
-// Make GET requests for the images URLs, decode then to jpeg format and return
-// an array of ImageInfo, which gives the image (binary) and the 
-// image file name (retrieved from the URL).
-func getJpegImgs(imagesUrl []Url) ([]ImageInfo, error) {
-	images := make([]ImageInfo, len(imagesUrl))
-	for i, img := range imagesUrl {
-		imgurl := img.Url
-		resp, err := http.Get(imgurl)
-		if err != nil {
-    	return nil, err
-    }
-		defer resp.Body.Close()
-		
-		img, err := jpeg.Decode(resp.Body)
-    if err != nil {
-    	return nil, err
-    }
-    images[i].img = img
-    err, images[i].name = nameFromUrl(imgurl)
-    if err != nil {
-			return nil, err	
-		}
-  }
-  return images, nil
-}
-
-// Resizes the image 'img' to the dimensions provided by 'newsize'.
-// Uses the Bilinear method for the resizing operation.
-func resizeImg(img image.Image, newsize ImageSize) image.Image {
-	return resize.Resize(newsize.width, newsize.height, img, resize.Bilinear)
-}
-
-// Saves the jpeg image to a file on the file system.
-func saveImgToFile(img image.Image, filepath string) error {
-	file, err := os.Create(filepath)
-  if err != nil {
-  	return err
-  }
-  return jpeg.Encode(file, img, nil)
-}
-
-// Resizes the given image to the given dimensions.
-// Saves it to a local file and insert into the database the URL to access this image.
-func resizeAndStoreToDB (imgInfo ImageInfo, size ImageSize, db *mgo.Collection) error {
-  img := imgInfo.img
-	img = resizeImg(img, size)
-	// The new resized image name will contain it's dimensions.
-	name := imgInfo.name + "_" + strconv.Itoa(int(size.width)) + "x" + strconv.Itoa(int(size.height)) + ".jpg"
-	if err := saveImgToFile(img, filepathPrefix + name); err != nil {
+// Seeds the database from EndpointAddr, but only if the collection is
+// still empty. This lets the server be restarted without re-downloading
+// and re-resizing every image on every boot.
+func seedIfEmpty(db *mgo.Collection) error {
+	count, err := db.Count()
+	if err != nil {
 		return err
 	}
-	// Creates the document to be inserted on the database.
-	// This document will be used by database queries to retrieve the URL for this image.
-	doc := MongoDocument{Name: name, Url: "http://" + ServerAddr + "/skyhub/" + name}
-	nameId := struct {
-		Name string
-	} {
-		doc.Name,
+	if count > 0 {
+		fmt.Println("Collection " + Database + "/" + Collection + " already populated, skipping seed.\n")
+		return nil
 	}
-	// Updates the URL for this image name, or creates new entry if image name is new.
-	if _, err := db.Upsert(nameId, doc); err != nil {
-		return err
-	}
-	return nil
-}
-
-// Resizes all the images to 3 sizes (Small, Medium and Large), 
-// Saves then to local files and insert into the database URLs to access these files.
-func resizeAndStoreImgsToDB (images []ImageInfo, db *mgo.Collection) error {
-	for _, imgInfo := range images {
-		if err := resizeAndStoreToDB(imgInfo, smallSize, db); err != nil {
-			return err
-		}
-		if err := resizeAndStoreToDB(imgInfo, mediumSize, db); err != nil {
-			return err
-		}
-		if err := resizeAndStoreToDB(imgInfo, largeSize, db); err != nil {
-			return err
-		}
-	}
-	return nil
-}
 
-func main() {
 	fmt.Println("Downloading images URLs...")
 	imagesUrl, err := consumeEndpoint(EndpointAddr)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	fmt.Println("Downloaded images URLs!\n")
-	
-	fmt.Println("Downloading images...")
-	images, err := getJpegImgs(imagesUrl)
+
+	fmt.Println("Ingesting all the images: downloading, resizing and storing the URL paths in the database...")
+	report, err := Ingest(context.Background(), imagesUrl, IngestOptions{}, db, Store)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	fmt.Println("Downloaded images!\n")
-	
+	fmt.Printf("Done! Downloaded %v, failed %v.\n", report.Downloaded, report.Failed)
+	return nil
+}
+
+func main() {
 	fmt.Println("Connecting to the database...")
 	db, err := connectToDatabase(DatabaseAddr)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("Connected to the database " + Database + "/" + Collection + "!\n")
-	
-	fmt.Println("Resizing all the images, saving to the local filesystem and storing the URL paths in the dabatase...")
-	if err := resizeAndStoreImgsToDB (images, db); err != nil {
+
+	if err := seedIfEmpty(db); err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println("Done!\n")
-	
-	// Serve the files.
-	http.Handle("/skyhub/", http.StripPrefix("/skyhub/", http.FileServer(http.Dir("skyhub"))))
-	
-	http.HandleFunc("/skyhub", Skyhub)
+
 	fmt.Println("Listening on " + ServerAddr + "...\n")
-	log.Fatal(http.ListenAndServe(ServerAddr, nil))
+	log.Fatal(http.ListenAndServe(ServerAddr, newRouter()))
 }
 
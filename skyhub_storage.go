@@ -0,0 +1,182 @@
+// Pluggable storage backends for resized image files. Swapping the
+// backend only changes where bytes live and how their URL is built;
+// it requires no changes to MongoDocument or the database schema.
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// A place resized image files are written to and served from.
+type Storage interface {
+	// Put stores r under key and returns the URL it can be fetched from.
+	Put(ctx context.Context, key string, r io.Reader) (url string, err error)
+	// Get opens the object stored under key. Caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key.
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL for key, whether or not it exists.
+	URL(key string) string
+}
+
+// Stores files on the local filesystem, rooted at Root. This is the
+// storage backend the server has always used.
+type LocalFS struct {
+	Root string
+	BaseUrl string
+}
+
+func NewLocalFS(root, baseUrl string) *LocalFS {
+	return &LocalFS{Root: root, BaseUrl: baseUrl}
+}
+
+func (s *LocalFS) Put(ctx context.Context, key string, r io.Reader) (string, error) {
+	file, err := os.Create(filepath.Join(s.Root, key))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return "", err
+	}
+	return s.URL(key), nil
+}
+
+func (s *LocalFS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.Root, key))
+}
+
+func (s *LocalFS) Delete(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(s.Root, key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalFS) URL(key string) string {
+	return s.BaseUrl + key
+}
+
+// Stores files in an S3 (or S3-compatible, e.g. MinIO) bucket.
+type S3 struct {
+	Client *s3.Client
+	Bucket string
+	Region string
+	// Endpoint overrides the default AWS endpoint, for MinIO compatibility.
+	Endpoint string
+}
+
+// Builds an S3 backend for bucket in region. If endpoint is non-empty,
+// requests are sent there instead of the default AWS endpoint.
+func NewS3(ctx context.Context, bucket, region, endpoint string) (*S3, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &S3{Client: client, Bucket: bucket, Region: region, Endpoint: endpoint}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) (string, error) {
+	_, err := s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key: aws.String(key),
+		Body: r,
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.URL(key), nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key: aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key: aws.String(key),
+	})
+	return err
+}
+
+func (s *S3) URL(key string) string {
+	if s.Endpoint != "" {
+		return s.Endpoint + "/" + s.Bucket + "/" + key
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, key)
+}
+
+// Keeps objects in memory. Used by tests so they don't touch disk or
+// a real object store.
+type Memory struct {
+	BaseUrl string
+
+	mu sync.Mutex
+	objects map[string][]byte
+}
+
+func NewMemory(baseUrl string) *Memory {
+	return &Memory{BaseUrl: baseUrl, objects: make(map[string][]byte)}
+}
+
+func (s *Memory) Put(ctx context.Context, key string, r io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.objects[key] = data
+	s.mu.Unlock()
+	return s.URL(key), nil
+}
+
+func (s *Memory) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	data, ok := s.objects[key]
+	s.mu.Unlock()
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *Memory) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.objects, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Memory) URL(key string) string {
+	return s.BaseUrl + key
+}
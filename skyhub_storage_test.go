@@ -0,0 +1,43 @@
+// Tests the in-memory Storage backend.
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMemoryStoragePutGetDelete(t *testing.T) {
+	store := NewMemory("http://example.test/skyhub/")
+	ctx := context.Background()
+
+	url, err := store.Put(ctx, "a.jpg", bytes.NewReader([]byte("hello")))
+	if err != nil {
+		t.Fatalf("Put failed: %v!", err)
+	}
+	if url != "http://example.test/skyhub/a.jpg" {
+		t.Fatalf("Unexpected URL: %v!", url)
+	}
+
+	r, err := store.Get(ctx, "a.jpg")
+	if err != nil {
+		t.Fatalf("Get failed: %v!", err)
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Can't read back stored object: %v!", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("Expected %q, got %q!", "hello", string(data))
+	}
+
+	if err := store.Delete(ctx, "a.jpg"); err != nil {
+		t.Fatalf("Delete failed: %v!", err)
+	}
+	if _, err := store.Get(ctx, "a.jpg"); !os.IsNotExist(err) {
+		t.Fatalf("Expected a not-exist error after delete, got %v!", err)
+	}
+}
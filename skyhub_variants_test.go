@@ -0,0 +1,50 @@
+// Tests the Fit mode resizing logic.
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solidImage(w, h int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: 128})
+		}
+	}
+	return img
+}
+
+func TestResizeVariantStretch(t *testing.T) {
+	v := Variant{Name: "v", Width: 100, Height: 50, Fit: Stretch}
+	out := resizeVariant(solidImage(200, 200), v)
+	if out.Bounds().Dx() != 100 || out.Bounds().Dy() != 50 {
+		t.Fatalf("Expected 100x50, got %vx%v!", out.Bounds().Dx(), out.Bounds().Dy())
+	}
+}
+
+func TestResizeVariantFit(t *testing.T) {
+	v := Variant{Name: "v", Width: 100, Height: 100, Fit: Fit}
+	out := resizeVariant(solidImage(200, 100), v)
+	if out.Bounds().Dx() != 100 || out.Bounds().Dy() != 50 {
+		t.Fatalf("Expected a 100x50 letterbox-free fit, got %vx%v!", out.Bounds().Dx(), out.Bounds().Dy())
+	}
+}
+
+func TestResizeVariantFill(t *testing.T) {
+	v := Variant{Name: "v", Width: 100, Height: 100, Fit: Fill}
+	out := resizeVariant(solidImage(200, 100), v)
+	if out.Bounds().Dx() != 100 || out.Bounds().Dy() != 100 {
+		t.Fatalf("Expected a 100x100 center-cropped fill, got %vx%v!", out.Bounds().Dx(), out.Bounds().Dy())
+	}
+}
+
+func TestResizeVariantCrop(t *testing.T) {
+	v := Variant{Name: "v", Width: 50, Height: 50, Fit: Crop, CropX: 10, CropY: 10, CropW: 80, CropH: 80}
+	out := resizeVariant(solidImage(200, 200), v)
+	if out.Bounds().Dx() != 50 || out.Bounds().Dy() != 50 {
+		t.Fatalf("Expected 50x50, got %vx%v!", out.Bounds().Dx(), out.Bounds().Dy())
+	}
+}